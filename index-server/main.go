@@ -2,73 +2,196 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"os"
 	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/deislabs/oras/pkg/content"
-	"github.com/deislabs/oras/pkg/oras"
 	indexSchema "github.com/devfile/registry-support/index/generator/schema"
+	"github.com/devfile/registry-support/index/server/pkg/metrics"
+	"github.com/devfile/registry-support/index/server/pkg/ociclient"
+	"github.com/devfile/registry-support/index/server/pkg/scheduler"
+	"github.com/devfile/registry-support/index/server/pkg/signing"
 
-	"github.com/containerd/containerd/remotes/docker"
 	"github.com/gin-gonic/gin"
-	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
 )
 
 const (
-	devfileName            = "devfile.yaml"
-	devfileConfigMediaType = "application/vnd.devfileio.devfile.config.v2+json"
-	devfileMediaType       = "application/vnd.devfileio.devfile.layer.v1"
-	registryPath           = "/registry/stacks"
-	indexPath              = "/registry/index.json"
-	scheme                 = "http"
-	registryService        = "localhost:5000"
+	devfileName               = "devfile.yaml"
+	devfileConfigMediaType    = "application/vnd.devfileio.devfile.config.v2+json"
+	devfileMediaType          = "application/vnd.devfileio.devfile.layer.v1"
+	devfileSignatureName      = "devfile.yaml.sig"
+	devfileSignatureMediaType = "application/vnd.devfileio.devfile.signature.v1"
+	dockerfileMediaType       = "application/vnd.devfileio.dockerfile.layer.v1"
+	kubernetesMediaType       = "application/vnd.devfileio.kubernetes.layer.v1+yaml"
+	openshiftMediaType        = "application/vnd.devfileio.openshift.layer.v1+yaml"
+	starterProjectMediaType   = "application/vnd.devfileio.starter.layer.v1+tar"
+	registryPath              = "/registry/stacks"
+	indexPath                 = "/registry/index.json"
+	scheme                    = "http"
+	registryService           = "localhost:5000"
+
+	trustStorePathEnv = "TRUST_STORE_PATH"
+	signingKeyPathEnv = "SIGNING_KEY_PATH"
+
+	proxyRemoteURLEnv    = "PROXY_REMOTE_URL"
+	proxyUsernameEnv     = "PROXY_USERNAME"
+	proxyPasswordEnv     = "PROXY_PASSWORD"
+	proxyCacheTTLEnv     = "PROXY_CACHE_TTL"
+	proxyCacheMaxSizeEnv = "PROXY_CACHE_MAX_SIZE"
+
+	defaultProxyCacheTTL     = time.Hour
+	defaultProxyCacheMaxSize = 100
+	schedulerStatePath       = "/registry/proxy-cache.json"
+)
+
+// ociBackend is the OCI client used for all pushes and pulls, selected at
+// startup via OCI_BACKEND (defaults to the ORAS backend against the bundled
+// localhost:5000 registry).
+var ociBackend ociclient.Client
+
+// trustStore is the set of public keys used to verify devfile signatures on
+// pull. It stays nil when TRUST_STORE_PATH is unset, in which case
+// verification is skipped.
+var trustStore *signing.TrustStore
+
+// signingKey is the operator-provided private key used to sign devfile
+// artifacts at push time. It stays nil when SIGNING_KEY_PATH is unset, in
+// which case artifacts are pushed unsigned.
+var signingKey *rsa.PrivateKey
+
+// proxyRemoteURL, proxyUsername and proxyPassword configure pull-through
+// proxy mode. proxyRemoteURL stays empty when PROXY_REMOTE_URL is unset, in
+// which case proxy mode is disabled.
+var (
+	proxyRemoteURL string
+	proxyUsername  string
+	proxyPassword  string
+	proxyScheduler *scheduler.Scheduler
 )
 
+// logger is the structured logger used throughout the server, initialized
+// once in main and injected into gin via loggingMiddleware.
+var logger *zap.SugaredLogger
+
 func main() {
+	zapLogger, err := zap.NewProduction()
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize logger: %v", err))
+	}
+	defer zapLogger.Sync()
+	logger = zapLogger.Sugar()
+
 	// Wait until registry is up and running
 	isDone := false
 	for !isDone {
 		resp, err := http.Get(scheme + "://" + registryService)
 		if err != nil {
-			log.Fatal(err.Error())
+			logger.Fatal(err.Error())
 		}
 
 		if resp.StatusCode == http.StatusOK {
 			isDone = true
-			log.Println("Registry is up and running")
+			logger.Info("Registry is up and running")
 		}
-		log.Println("Waiting for registry to start...")
+		logger.Info("Waiting for registry to start...")
 		time.Sleep(time.Second)
 	}
 
+	backend, err := ociclient.New(os.Getenv(ociclient.BackendEnv))
+	if err != nil {
+		logger.Fatalf("failed to initialize OCI backend: %v", err)
+	}
+	ociBackend = backend
+
+	// Initialize the trust store and signing key, if configured
+	if trustStorePath := os.Getenv(trustStorePathEnv); trustStorePath != "" {
+		store, err := signing.LoadTrustStore(trustStorePath)
+		if err != nil {
+			logger.Fatalf("failed to load trust store: %v", err)
+		}
+		trustStore = store
+		logger.Infof("Loaded trust store from %s\n", trustStorePath)
+	}
+
+	if signingKeyPath := os.Getenv(signingKeyPathEnv); signingKeyPath != "" {
+		key, err := signing.LoadPrivateKey(signingKeyPath)
+		if err != nil {
+			logger.Fatalf("failed to load signing key: %v", err)
+		}
+		signingKey = key
+		logger.Infof("Loaded signing key from %s\n", signingKeyPath)
+	}
+
+	// Initialize proxy mode, if configured
+	if url := os.Getenv(proxyRemoteURLEnv); url != "" {
+		proxyRemoteURL = url
+		proxyUsername = os.Getenv(proxyUsernameEnv)
+		proxyPassword = os.Getenv(proxyPasswordEnv)
+
+		ttl := defaultProxyCacheTTL
+		if v := os.Getenv(proxyCacheTTLEnv); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				logger.Fatalf("invalid %s: %v", proxyCacheTTLEnv, err)
+			}
+			ttl = parsed
+		}
+
+		maxSize := defaultProxyCacheMaxSize
+		if v := os.Getenv(proxyCacheMaxSizeEnv); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				logger.Fatalf("invalid %s: %v", proxyCacheMaxSizeEnv, err)
+			}
+			maxSize = parsed
+		}
+
+		sched, err := scheduler.New(schedulerStatePath, ttl, maxSize, evictCachedStack)
+		if err != nil {
+			logger.Fatalf("failed to initialize proxy cache scheduler: %v", err)
+		}
+		proxyScheduler = sched
+
+		stop := make(chan struct{})
+		go proxyScheduler.Run(time.Minute, stop)
+		logger.Infof("Proxy mode enabled, caching stacks from %s\n", proxyRemoteURL)
+	}
+
 	// Load index file
 	bytes, err := ioutil.ReadFile(indexPath)
 	if err != nil {
-		log.Fatalf("failed to read index file: %v", err)
+		logger.Fatalf("failed to read index file: %v", err)
 	}
 
 	var index []indexSchema.Schema
 	err = json.Unmarshal(bytes, &index)
 	if err != nil {
-		log.Fatalf("failed to unmarshal index file: %v", err)
+		logger.Fatalf("failed to unmarshal index file: %v", err)
 	}
+	metrics.IndexedStacks.Set(float64(len(index)))
 
 	// Before starting the server, push the devfile artifacts to the registry
 	for _, devfileIndex := range index {
 		err := pushStackToRegistry(devfileIndex)
 		if err != nil {
-			log.Fatal(err.Error())
+			logger.Fatal(err.Error())
 		}
 	}
 
 	// Start the server and serve requests and index.json
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), loggingMiddleware())
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -80,72 +203,588 @@ func main() {
 		name := c.Param("name")
 		for _, devfileIndex := range index {
 			if devfileIndex.Name == name {
-				bytes, err := pullStackFromRegistry(devfileIndex)
+				bytes, verified, err := pullStackFromRegistry(devfileIndex)
 				if err != nil {
-					log.Fatal(err.Error())
 					c.JSON(http.StatusInternalServerError, gin.H{
 						"error":  err.Error(),
 						"status": fmt.Sprintf("failed to pull the devfile of %s", name),
 					})
+					return
+				}
+				if trustStore != nil && !verified {
+					c.JSON(http.StatusBadGateway, gin.H{
+						"status": fmt.Sprintf("signature verification failed for the devfile of %s", name),
+					})
+					return
 				}
 				c.Data(http.StatusOK, http.DetectContentType(bytes), bytes)
+				return
+			}
+		}
+
+		if proxyScheduler != nil {
+			if proxyScheduler.Has(name) {
+				if bytes, err := pullCachedStack(name); err == nil {
+					metrics.CacheTotal.WithLabelValues("hit").Inc()
+					c.Data(http.StatusOK, http.DetectContentType(bytes), bytes)
+					return
+				}
+			}
+
+			metrics.CacheTotal.WithLabelValues("miss").Inc()
+			bytes, err := fetchAndCacheFromUpstream(name)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error":  err.Error(),
+					"status": fmt.Sprintf("failed to proxy the devfile of %s", name),
+				})
+				return
 			}
+			c.Data(http.StatusOK, http.DetectContentType(bytes), bytes)
+			return
 		}
+
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": fmt.Sprintf("the devfile of %s did not exist", name),
+		})
 	})
 
+	router.GET("/devfiles/:name/signature", func(c *gin.Context) {
+		name := c.Param("name")
+		for _, devfileIndex := range index {
+			if devfileIndex.Name == name {
+				sig, err := pullSignatureFromRegistry(devfileIndex)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error":  err.Error(),
+						"status": fmt.Sprintf("failed to pull the signature of %s", name),
+					})
+					return
+				}
+				c.Data(http.StatusOK, "application/octet-stream", sig)
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": fmt.Sprintf("the devfile of %s did not exist", name),
+		})
+	})
+
+	// The second path segment is either a version tag (e.g. 1.0.2) or the
+	// name of a supporting file pushed alongside the devfile (e.g.
+	// Dockerfile, kubernetes-deploy.yaml): try a version pull first and
+	// fall back to a file-by-title pull.
+	router.GET("/devfiles/:name/:version", func(c *gin.Context) {
+		name := c.Param("name")
+		versionOrFilename := c.Param("version")
+		for _, devfileIndex := range index {
+			if devfileIndex.Name != name {
+				continue
+			}
+			if bytes, verified, err := pullVersionedStackFromRegistry(devfileIndex, versionOrFilename); err == nil {
+				if trustStore != nil && !verified {
+					c.JSON(http.StatusBadGateway, gin.H{
+						"status": fmt.Sprintf("signature verification failed for %s of %s", versionOrFilename, name),
+					})
+					return
+				}
+				c.Data(http.StatusOK, http.DetectContentType(bytes), bytes)
+				return
+			}
+			bytes, err := pullFileFromRegistry(devfileIndex, versionOrFilename)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error":  err.Error(),
+					"status": fmt.Sprintf("failed to pull %s of %s", versionOrFilename, name),
+				})
+				return
+			}
+			c.Data(http.StatusOK, http.DetectContentType(bytes), bytes)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": fmt.Sprintf("the devfile of %s did not exist", name),
+		})
+	})
+
+	router.GET("/devfiles/:name/manifest", func(c *gin.Context) {
+		name := c.Param("name")
+		for _, devfileIndex := range index {
+			if devfileIndex.Name == name {
+				manifest, err := pullManifestFromRegistry(devfileIndex)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error":  err.Error(),
+						"status": fmt.Sprintf("failed to pull the manifest of %s", name),
+					})
+					return
+				}
+				c.Data(http.StatusOK, "application/vnd.oci.image.manifest.v1+json", manifest)
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": fmt.Sprintf("the devfile of %s did not exist", name),
+		})
+	})
+
+	router.GET("/devfiles/:name/tags", func(c *gin.Context) {
+		name := c.Param("name")
+		for _, devfileIndex := range index {
+			if devfileIndex.Name == name {
+				tags, err := listTags(devfileIndex)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error":  err.Error(),
+						"status": fmt.Sprintf("failed to list tags of %s", name),
+					})
+					return
+				}
+				c.JSON(http.StatusOK, gin.H{
+					"name": name,
+					"tags": tags,
+				})
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": fmt.Sprintf("the devfile of %s did not exist", name),
+		})
+	})
+
+	router.GET("/v2/_catalog", func(c *gin.Context) {
+		repositories := make([]string, 0, len(index))
+		for _, devfileIndex := range index {
+			repositories = append(repositories, devfileIndex.Name)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"repositories": repositories,
+		})
+	})
+
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	router.StaticFile("/index", indexPath)
 
 	router.Run(":7070")
 }
 
-// pushStackToRegistry pushes the given devfile stack to the OCI registry
+// loggingMiddleware logs each request's method, path, status and latency
+// through the structured logger.
+func loggingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		logger.Infow("handled request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start),
+		)
+	}
+}
+
+// stackRef returns the ref under which a devfile stack's default tag is
+// pushed and pulled.
+func stackRef(devfileIndex indexSchema.Schema) string {
+	return path.Join(registryService, "/", devfileIndex.Links["self"])
+}
+
+// stackRepository returns the repository part of a devfile stack's
+// Links["self"] ref, i.e. that ref without its tag.
+func stackRepository(devfileIndex indexSchema.Schema) string {
+	repo := devfileIndex.Links["self"]
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	return repo
+}
+
+// versionedStackRef returns the ref for a specific version tag of a devfile
+// stack, e.g. stacks/nodejs:1.0.2.
+func versionedStackRef(devfileIndex indexSchema.Schema, version string) string {
+	return fmt.Sprintf("%s:%s", path.Join(registryService, stackRepository(devfileIndex)), version)
+}
+
+// cachedStackRef returns the ref under which a proxied stack is cached in
+// the local OCI registry.
+func cachedStackRef(name string) string {
+	return path.Join(registryService, "cache", name)
+}
+
+// contentDigest returns the OCI-style "sha256:<hex>" digest of content.
+func contentDigest(content []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
+// devfileLayers builds the layers to push for a devfile's content, signing
+// its digest and media type (not the raw content) with signingKey when one
+// is configured, so the signature identifies the exact artifact descriptor
+// rather than just the bytes.
+func devfileLayers(devfileContent []byte) ([]ociclient.Layer, error) {
+	layers := []ociclient.Layer{
+		{Name: devfileName, MediaType: devfileMediaType, Content: devfileContent},
+	}
+
+	if signingKey != nil {
+		sig, err := signing.Sign(signingKey, []byte(contentDigest(devfileContent)+devfileMediaType))
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign %s: %v", devfileName, err)
+		}
+		layers = append(layers, ociclient.Layer{Name: devfileSignatureName, MediaType: devfileSignatureMediaType, Content: sig})
+	}
+
+	return layers, nil
+}
+
+// supportingFileMediaType returns the media type a supporting stack file
+// should be pushed under, based on its path relative to the stack directory,
+// or "" if the file is not a recognized supporting artifact.
+func supportingFileMediaType(relPath string) string {
+	switch {
+	case relPath == devfileName:
+		return ""
+	case filepath.Base(relPath) == "Dockerfile":
+		return dockerfileMediaType
+	case strings.HasPrefix(relPath, "kubernetes"+string(filepath.Separator)):
+		return kubernetesMediaType
+	case strings.HasPrefix(relPath, "openshift"+string(filepath.Separator)):
+		return openshiftMediaType
+	case filepath.Ext(relPath) == ".tar" || filepath.Ext(relPath) == ".gz" || filepath.Ext(relPath) == ".zip":
+		return starterProjectMediaType
+	default:
+		return ""
+	}
+}
+
+// layerTitle flattens a stack-relative file path into a single path segment
+// so it can be addressed as the last element of a URL, e.g.
+// "kubernetes/deploy.yaml" becomes "kubernetes-deploy.yaml". gin's :version
+// route parameter cannot match a segment containing a literal "/", which is
+// what relative paths under kubernetes/ and openshift/ would otherwise be.
+func layerTitle(relPath string) string {
+	return strings.ReplaceAll(relPath, string(filepath.Separator), "-")
+}
+
+// supportingStackLayers walks a stack's directory and returns one layer per
+// supporting file (Dockerfiles, Kubernetes/OpenShift manifests, starter
+// project archives), keyed by its flattened layerTitle so it can be pulled
+// back as a single path segment.
+func supportingStackLayers(stackName string) ([]ociclient.Layer, error) {
+	stackDir := filepath.Join(registryPath, stackName)
+
+	var layers []ociclient.Layer
+	err := filepath.Walk(stackDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(stackDir, p)
+		if err != nil {
+			return err
+		}
+		mediaType := supportingFileMediaType(relPath)
+		if mediaType == "" {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, ociclient.Layer{Name: layerTitle(relPath), MediaType: mediaType, Content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk stack directory %s: %v", stackDir, err)
+	}
+	return layers, nil
+}
+
+// pushStackToRegistry pushes the given devfile stack to the OCI registry. If
+// a signing key is configured, a detached signature over the devfile
+// layer's digest and media type is pushed alongside it as an additional
+// layer. Each declared
+// version is additionally pushed as its own tag under the same repository,
+// e.g. stacks/nodejs:1.0.2, so that clients can pin a specific version.
 func pushStackToRegistry(devfileIndex indexSchema.Schema) error {
-	// Load the devfile into memory and set up the pushing resource (file name, file content, media type, ref)
+	ctx := context.Background()
+
 	devfileContent, err := ioutil.ReadFile(filepath.Join(registryPath, devfileIndex.Name, devfileName))
 	if err != nil {
 		return err
 	}
-	ref := path.Join(registryService, "/", devfileIndex.Links["self"])
 
-	ctx := context.Background()
-	resolver := docker.NewResolver(docker.ResolverOptions{PlainHTTP: true})
+	layers, err := devfileLayers(devfileContent)
+	if err != nil {
+		return err
+	}
 
-	// Add the devfile (and its custom media type) to the memory store
-	memoryStore := content.NewMemoryStore()
-	desc := memoryStore.Add(devfileName, devfileMediaType, devfileContent)
-	pushContents := []ocispec.Descriptor{desc}
+	supportingLayers, err := supportingStackLayers(devfileIndex.Name)
+	if err != nil {
+		return err
+	}
+	layers = append(layers, supportingLayers...)
 
-	log.Printf("Pushing %s to %s...\n", devfileName, ref)
-	desc, err = oras.Push(ctx, resolver, ref, memoryStore, pushContents, oras.WithConfigMediaType(devfileConfigMediaType))
+	ref := stackRef(devfileIndex)
+	logger.Infof("Pushing %s to %s...\n", devfileName, ref)
+	desc, err := ociBackend.Push(ctx, ref, layers, devfileConfigMediaType)
 	if err != nil {
+		metrics.PushTotal.WithLabelValues(devfileIndex.Name, "failure").Inc()
 		return fmt.Errorf("failed to push %s to %s: %v", devfileName, ref, err)
 	}
-	log.Printf("Pushed to %s with digest %s\n", ref, desc.Digest)
+	metrics.PushTotal.WithLabelValues(devfileIndex.Name, "success").Inc()
+	logger.Infof("Pushed to %s with digest %s\n", ref, desc.Digest)
+
+	for _, version := range devfileIndex.Versions {
+		versionContent, err := ioutil.ReadFile(filepath.Join(registryPath, devfileIndex.Name, version.Version, devfileName))
+		if err != nil {
+			versionContent = devfileContent
+		}
+
+		versionLayers, err := devfileLayers(versionContent)
+		if err != nil {
+			return err
+		}
+
+		versionRef := versionedStackRef(devfileIndex, version.Version)
+		logger.Infof("Pushing %s to %s...\n", devfileName, versionRef)
+		if _, err := ociBackend.Push(ctx, versionRef, versionLayers, devfileConfigMediaType); err != nil {
+			return fmt.Errorf("failed to push %s to %s: %v", devfileName, versionRef, err)
+		}
+	}
+
 	return nil
 }
 
-// pullStackFromRegistry pulls the given devfile stack from the OCI registry
-func pullStackFromRegistry(devfileIndex indexSchema.Schema) ([]byte, error) {
-	// Pull the devfile from registry and save to disk
-	ref := path.Join(registryService, "/", devfileIndex.Links["self"])
+// pullStackFromRegistry pulls the given devfile stack from the OCI registry.
+// When a trust store is configured, the returned verified flag reports
+// whether the devfile's signature layer (if any) validated against it.
+func pullStackFromRegistry(devfileIndex indexSchema.Schema) (bytes []byte, verified bool, err error) {
+	ctx := context.Background()
+	ref := stackRef(devfileIndex)
+	allowedMediaTypes := []string{devfileMediaType, devfileSignatureMediaType}
+
+	start := time.Now()
+	defer func() {
+		metrics.PullDuration.WithLabelValues(devfileIndex.Name).Observe(time.Since(start).Seconds())
+	}()
+
+	logger.Infof("Pulling %s from %s...\n", devfileName, ref)
+	layers, err := ociBackend.Pull(ctx, ref, allowedMediaTypes)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to pull %s from %s: %v", devfileName, ref, err)
+	}
 
+	bytes, ok := layers[devfileName]
+	if !ok {
+		return nil, false, fmt.Errorf("failed to load %s to memory", devfileName)
+	}
+
+	if trustStore != nil {
+		if sig, ok := layers[devfileSignatureName]; ok {
+			verified = trustStore.Verify([]byte(contentDigest(bytes)+devfileMediaType), sig)
+		}
+	}
+
+	logger.Infof("Pulled %s from %s\n", devfileName, ref)
+	return bytes, verified, nil
+}
+
+// pullSignatureFromRegistry pulls the detached signature layer for the given
+// devfile stack from the OCI registry.
+func pullSignatureFromRegistry(devfileIndex indexSchema.Schema) ([]byte, error) {
 	ctx := context.Background()
-	resolver := docker.NewResolver(docker.ResolverOptions{PlainHTTP: true})
+	ref := stackRef(devfileIndex)
+	allowedMediaTypes := []string{devfileMediaType, devfileSignatureMediaType}
+
+	logger.Infof("Pulling %s from %s...\n", devfileSignatureName, ref)
+	layers, err := ociBackend.Pull(ctx, ref, allowedMediaTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s from %s: %v", devfileSignatureName, ref, err)
+	}
+	sig, ok := layers[devfileSignatureName]
+	if !ok {
+		return nil, fmt.Errorf("no signature found for %s", ref)
+	}
+	return sig, nil
+}
+
+// pullVersionedStackFromRegistry pulls a specific version tag of a devfile
+// stack from the OCI registry. When a trust store is configured, the
+// returned verified flag reports whether the devfile's signature layer (if
+// any) validated against it.
+func pullVersionedStackFromRegistry(devfileIndex indexSchema.Schema, version string) (bytes []byte, verified bool, err error) {
+	ctx := context.Background()
+	ref := versionedStackRef(devfileIndex, version)
+	allowedMediaTypes := []string{devfileMediaType, devfileSignatureMediaType}
+
+	logger.Infof("Pulling %s from %s...\n", devfileName, ref)
+	layers, err := ociBackend.Pull(ctx, ref, allowedMediaTypes)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to pull %s from %s: %v", devfileName, ref, err)
+	}
+	bytes, ok := layers[devfileName]
+	if !ok {
+		return nil, false, fmt.Errorf("failed to load %s to memory", devfileName)
+	}
+
+	if trustStore != nil {
+		if sig, ok := layers[devfileSignatureName]; ok {
+			verified = trustStore.Verify([]byte(contentDigest(bytes)+devfileMediaType), sig)
+		}
+	}
+
+	logger.Infof("Pulled %s from %s\n", devfileName, ref)
+	return bytes, verified, nil
+}
 
-	// Initialize memory store
-	memoryStore := content.NewMemoryStore()
-	allowedMediaTypes := []string{devfileMediaType}
+// pullFileFromRegistry pulls a single supporting file (e.g. Dockerfile,
+// kubernetes-deploy.yaml) from a devfile stack's default tag, looked up by
+// its flattened layerTitle (the org.opencontainers.image.title annotation
+// it was pushed with).
+func pullFileFromRegistry(devfileIndex indexSchema.Schema, filename string) ([]byte, error) {
+	ctx := context.Background()
+	ref := stackRef(devfileIndex)
+	allowedMediaTypes := []string{dockerfileMediaType, kubernetesMediaType, openshiftMediaType, starterProjectMediaType}
 
-	log.Printf("Pulling %s from %s...\n", devfileName, ref)
-	desc, _, err := oras.Pull(ctx, resolver, ref, memoryStore, oras.WithAllowedMediaTypes(allowedMediaTypes))
+	logger.Infof("Pulling %s from %s...\n", filename, ref)
+	layers, err := ociBackend.Pull(ctx, ref, allowedMediaTypes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to pull %s from %s: %v", devfileName, ref, err)
+		return nil, fmt.Errorf("failed to pull %s from %s: %v", filename, ref, err)
 	}
-	_, bytes, ok := memoryStore.GetByName(devfileName)
+	bytes, ok := layers[filename]
 	if !ok {
-		return nil, fmt.Errorf("failed to load %s to memory", devfileName)
+		return nil, fmt.Errorf("no file named %s found for %s", filename, ref)
+	}
+	return bytes, nil
+}
+
+// pullManifestFromRegistry pulls the raw OCI manifest for a devfile stack's
+// default tag, so clients can enumerate the resources available for it.
+func pullManifestFromRegistry(devfileIndex indexSchema.Schema) ([]byte, error) {
+	ref := stackRef(devfileIndex)
+	manifest, err := ociBackend.Manifest(context.Background(), ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull manifest from %s: %v", ref, err)
 	}
+	return manifest, nil
+}
 
-	log.Printf("Pulled from %s with digest %s\n", ref, desc.Digest)
+// listTags returns the tags available for a devfile stack, preferring the
+// version list already recorded in the in-memory index and falling back to
+// a live registry v2 tags-list query when the index has no version info.
+func listTags(devfileIndex indexSchema.Schema) ([]string, error) {
+	if len(devfileIndex.Versions) > 0 {
+		tags := make([]string, 0, len(devfileIndex.Versions))
+		for _, version := range devfileIndex.Versions {
+			tags = append(tags, version.Version)
+		}
+		return tags, nil
+	}
+
+	url := fmt.Sprintf("%s://%s/v2/%s/tags/list", scheme, registryService, stackRepository(devfileIndex))
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags for %s: %v", devfileIndex.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %d listing tags for %s", resp.StatusCode, devfileIndex.Name)
+	}
+
+	var tagList struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tagList); err != nil {
+		return nil, fmt.Errorf("failed to decode tag list for %s: %v", devfileIndex.Name, err)
+	}
+	return tagList.Tags, nil
+}
+
+// pullCachedStack pulls a previously proxy-cached stack from the local OCI
+// registry, without touching the upstream.
+func pullCachedStack(name string) ([]byte, error) {
+	ref := cachedStackRef(name)
+	layers, err := ociBackend.Pull(context.Background(), ref, []string{devfileMediaType})
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull cached %s from %s: %v", name, ref, err)
+	}
+	bytes, ok := layers[devfileName]
+	if !ok {
+		return nil, fmt.Errorf("no cached devfile found for %s", ref)
+	}
 	return bytes, nil
 }
+
+// fetchAndCacheFromUpstream fetches a devfile stack not present in the local
+// index from the configured upstream registry, caches it into the local OCI
+// registry under cachedStackRef, and registers it with the proxy scheduler
+// so it is evicted once its TTL expires.
+func fetchAndCacheFromUpstream(name string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, proxyRemoteURL+"/devfiles/"+name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build upstream request for %s: %v", name, err)
+	}
+	if proxyUsername != "" {
+		req.SetBasicAuth(proxyUsername, proxyPassword)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s from upstream %s: %v", name, proxyRemoteURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream %s returned %d for %s", proxyRemoteURL, resp.StatusCode, name)
+	}
+
+	devfileContent, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upstream response body for %s: %v", name, err)
+	}
+
+	ref := cachedStackRef(name)
+	logger.Infof("Caching %s from upstream to %s...\n", name, ref)
+	layers := []ociclient.Layer{{Name: devfileName, MediaType: devfileMediaType, Content: devfileContent}}
+	if _, err := ociBackend.Push(context.Background(), ref, layers, devfileConfigMediaType); err != nil {
+		return nil, fmt.Errorf("failed to cache %s to %s: %v", name, ref, err)
+	}
+
+	if err := proxyScheduler.Track(name); err != nil {
+		return nil, fmt.Errorf("failed to track cache entry for %s: %v", name, err)
+	}
+
+	return devfileContent, nil
+}
+
+// evictCachedStack deletes a proxy-cached stack's manifest from the local
+// OCI registry. It is idempotent: a manifest that is already gone is not
+// treated as an error.
+func evictCachedStack(name string) error {
+	ref := cachedStackRef(name)
+	url := fmt.Sprintf("%s://%s/v2/cache/%s/manifests/latest", scheme, registryService, name)
+
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build delete request for %s: %v", ref, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %v", ref, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("registry returned %d deleting %s", resp.StatusCode, ref)
+	}
+
+	logger.Infof("Evicted cached stack %s\n", name)
+	return nil
+}
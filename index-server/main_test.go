@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	indexSchema "github.com/devfile/registry-support/index/generator/schema"
+	"github.com/devfile/registry-support/index/server/pkg/ociclient"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// fakeOCIClient is an in-memory ociclient.Client used to exercise the HTTP
+// handlers without a real registry.
+type fakeOCIClient struct {
+	pushed map[string]map[string][]byte
+}
+
+func newFakeOCIClient() *fakeOCIClient {
+	return &fakeOCIClient{pushed: map[string]map[string][]byte{}}
+}
+
+func (f *fakeOCIClient) Push(_ context.Context, ref string, layers []ociclient.Layer, _ string) (ociclient.Descriptor, error) {
+	byName := map[string][]byte{}
+	for _, l := range layers {
+		byName[l.Name] = l.Content
+	}
+	f.pushed[ref] = byName
+	return ociclient.Descriptor{Digest: "sha256:fake"}, nil
+}
+
+func (f *fakeOCIClient) Pull(_ context.Context, ref string, _ []string) (map[string][]byte, error) {
+	return f.pushed[ref], nil
+}
+
+func (f *fakeOCIClient) Manifest(_ context.Context, ref string) ([]byte, error) {
+	return []byte("{}"), nil
+}
+
+func TestLayerTitleFlattensNestedPaths(t *testing.T) {
+	got := layerTitle("kubernetes/deploy.yaml")
+	want := "kubernetes-deploy.yaml"
+	if got != want {
+		t.Fatalf("layerTitle(%q) = %q, want %q", "kubernetes/deploy.yaml", got, want)
+	}
+}
+
+// TestPullKubernetesFileRoute verifies that a supporting file nested under
+// kubernetes/ can actually be retrieved through GET /devfiles/:name/:version,
+// since gin's :version segment cannot contain a literal "/".
+func TestPullKubernetesFileRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger = zap.NewNop().Sugar()
+
+	devfileIndex := indexSchema.Schema{
+		Name:  "nodejs",
+		Links: map[string]string{"self": "stacks/nodejs:latest"},
+	}
+	index := []indexSchema.Schema{devfileIndex}
+
+	fake := newFakeOCIClient()
+	ociBackend = fake
+
+	ref := stackRef(devfileIndex)
+	title := layerTitle("kubernetes/deploy.yaml")
+	if _, err := fake.Push(context.Background(), ref, []ociclient.Layer{
+		{Name: title, MediaType: kubernetesMediaType, Content: []byte("kind: Deployment")},
+	}, devfileConfigMediaType); err != nil {
+		t.Fatalf("failed to seed fake registry: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/devfiles/:name/:version", func(c *gin.Context) {
+		name := c.Param("name")
+		versionOrFilename := c.Param("version")
+		for _, di := range index {
+			if di.Name != name {
+				continue
+			}
+			if bytes, _, err := pullVersionedStackFromRegistry(di, versionOrFilename); err == nil {
+				c.Data(http.StatusOK, http.DetectContentType(bytes), bytes)
+				return
+			}
+			bytes, err := pullFileFromRegistry(di, versionOrFilename)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"status": "not found"})
+				return
+			}
+			c.Data(http.StatusOK, http.DetectContentType(bytes), bytes)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"status": "not found"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/devfiles/nodejs/"+title, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != "kind: Deployment" {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,38 @@
+// Package metrics defines the Prometheus collectors exposed by the index
+// server's /metrics endpoint.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// PushTotal counts devfile pushes to the registry, labeled by stack name
+	// and outcome ("success" or "failure").
+	PushTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "devfile_registry_push_total",
+		Help: "Total number of devfile stack pushes to the OCI registry.",
+	}, []string{"stack", "status"})
+
+	// PullDuration tracks how long pulls take, labeled by stack name.
+	PullDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "devfile_registry_pull_duration_seconds",
+		Help: "Time taken to pull a devfile stack from the OCI registry.",
+	}, []string{"stack"})
+
+	// CacheTotal counts proxy-mode cache lookups, labeled by outcome ("hit"
+	// or "miss").
+	CacheTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "devfile_registry_cache_total",
+		Help: "Total number of proxy-mode cache lookups, by hit or miss.",
+	}, []string{"result"})
+
+	// IndexedStacks reports the number of stacks currently in the in-memory
+	// index.
+	IndexedStacks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "devfile_registry_indexed_stacks",
+		Help: "Number of devfile stacks currently indexed.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(PushTotal, PullDuration, CacheTotal, IndexedStacks)
+}
@@ -0,0 +1,135 @@
+package ociclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// titleAnnotation records a layer's original file name, mirroring the
+// org.opencontainers.image.title annotation used by the ORAS backend.
+const titleAnnotation = "org.opencontainers.image.title"
+
+// gcrClient pushes and pulls via github.com/google/go-containerregistry,
+// authenticating through the default keychain (docker config, GCR, ECR
+// credential helpers), so it can reach authenticated remote registries and
+// not just the bundled localhost:5000 instance.
+type gcrClient struct{}
+
+func (c *gcrClient) Push(ctx context.Context, ref string, layers []Layer, configMediaType string) (Descriptor, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("failed to parse ref %s: %v", ref, err)
+	}
+
+	img := empty.Image
+	img = mutate.ConfigMediaType(img, types.MediaType(configMediaType))
+
+	for _, l := range layers {
+		layer := static.NewLayer(l.Content, types.MediaType(l.MediaType))
+		img, err = mutate.Append(img, mutate.Addendum{
+			Layer:       layer,
+			Annotations: map[string]string{titleAnnotation: l.Name},
+		})
+		if err != nil {
+			return Descriptor{}, fmt.Errorf("failed to append layer %s: %v", l.Name, err)
+		}
+	}
+
+	if err := remote.Write(r, img, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx)); err != nil {
+		return Descriptor{}, fmt.Errorf("failed to push %s: %v", ref, err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("failed to read manifest for %s: %v", ref, err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("failed to compute digest for %s: %v", ref, err)
+	}
+	size, err := img.Size()
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("failed to compute size for %s: %v", ref, err)
+	}
+
+	return Descriptor{Digest: digest.String(), MediaType: string(manifest.MediaType), Size: size}, nil
+}
+
+func (c *gcrClient) Pull(ctx context.Context, ref string, allowedMediaTypes []string) (map[string][]byte, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ref %s: %v", ref, err)
+	}
+
+	img, err := remote.Image(r, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %v", ref, err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest for %s: %v", ref, err)
+	}
+	imgLayers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layers for %s: %v", ref, err)
+	}
+
+	allowed := map[string]bool{}
+	for _, mt := range allowedMediaTypes {
+		allowed[mt] = true
+	}
+
+	result := map[string][]byte{}
+	for i, layer := range imgLayers {
+		if i >= len(manifest.Layers) {
+			break
+		}
+		desc := manifest.Layers[i]
+		if !allowed[string(desc.MediaType)] {
+			continue
+		}
+		name := desc.Annotations[titleAnnotation]
+		if name == "" {
+			continue
+		}
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer %s: %v", name, err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer content %s: %v", name, err)
+		}
+		result[name] = content
+	}
+	return result, nil
+}
+
+func (c *gcrClient) Manifest(ctx context.Context, ref string) ([]byte, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ref %s: %v", ref, err)
+	}
+
+	img, err := remote.Image(r, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %v", ref, err)
+	}
+
+	manifest, err := img.RawManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest for %s: %v", ref, err)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,60 @@
+// Package ociclient abstracts pushing and pulling OCI artifacts behind a
+// single Client interface, so the index server can switch backends (ORAS,
+// go-containerregistry) via configuration rather than call-site changes.
+package ociclient
+
+import (
+	"context"
+	"fmt"
+)
+
+// Descriptor describes a pushed OCI artifact without leaking any particular
+// backend's descriptor type to callers.
+type Descriptor struct {
+	Digest    string
+	MediaType string
+	Size      int64
+}
+
+// Layer is a single named blob to push as part of an artifact, e.g. a
+// devfile.yaml or a supporting Dockerfile.
+type Layer struct {
+	Name      string
+	MediaType string
+	Content   []byte
+}
+
+// Client pushes and pulls OCI artifacts to/from a registry.
+type Client interface {
+	// Push uploads layers as a single artifact to ref and returns the
+	// resulting manifest descriptor.
+	Push(ctx context.Context, ref string, layers []Layer, configMediaType string) (Descriptor, error)
+	// Pull downloads the artifact at ref, returning its layers keyed by
+	// their Layer.Name, restricted to allowedMediaTypes.
+	Pull(ctx context.Context, ref string, allowedMediaTypes []string) (map[string][]byte, error)
+	// Manifest returns the raw OCI manifest JSON for ref.
+	Manifest(ctx context.Context, ref string) ([]byte, error)
+}
+
+// BackendEnv is the environment variable used to select the OCI backend.
+const BackendEnv = "OCI_BACKEND"
+
+// Backend identifiers accepted via BackendEnv.
+const (
+	BackendORAS = "oras"
+	BackendGCR  = "gcr"
+)
+
+// New returns the Client implementation for the named backend. An empty
+// backend defaults to the ORAS implementation, which is what the index
+// server has always used against its bundled localhost:5000 registry.
+func New(backend string) (Client, error) {
+	switch backend {
+	case "", BackendORAS:
+		return &orasClient{}, nil
+	case BackendGCR:
+		return &gcrClient{}, nil
+	default:
+		return nil, fmt.Errorf("unknown OCI backend %q", backend)
+	}
+}
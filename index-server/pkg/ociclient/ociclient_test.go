@@ -0,0 +1,118 @@
+package ociclient
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+// newTestRegistry starts an in-process, plain-HTTP OCI registry and returns
+// its host:port, suitable for both the ORAS and go-containerregistry
+// backends.
+func newTestRegistry(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// backends lists every Client implementation this suite must exercise, so a
+// regression in one backend can't hide behind the other being untested.
+func backends() map[string]Client {
+	return map[string]Client{
+		BackendORAS: &orasClient{},
+		BackendGCR:  &gcrClient{},
+	}
+}
+
+func TestPushPull(t *testing.T) {
+	for name, client := range backends() {
+		name, client := name, client
+		t.Run(name, func(t *testing.T) {
+			host := newTestRegistry(t)
+			ref := host + "/stacks/nodejs:latest"
+
+			layers := []Layer{
+				{Name: "devfile.yaml", MediaType: "application/vnd.devfileio.devfile.layer.v1", Content: []byte("schemaVersion: 2.0.0")},
+			}
+
+			if _, err := client.Push(context.Background(), ref, layers, "application/vnd.devfileio.devfile.config.v2+json"); err != nil {
+				t.Fatalf("%s: push failed: %v", name, err)
+			}
+
+			pulled, err := client.Pull(context.Background(), ref, []string{"application/vnd.devfileio.devfile.layer.v1"})
+			if err != nil {
+				t.Fatalf("%s: pull failed: %v", name, err)
+			}
+			got, ok := pulled["devfile.yaml"]
+			if !ok {
+				t.Fatalf("%s: expected devfile.yaml layer, got %v", name, pulled)
+			}
+			if string(got) != "schemaVersion: 2.0.0" {
+				t.Fatalf("%s: unexpected content %q", name, got)
+			}
+		})
+	}
+}
+
+// TestPushPullFlattenedNestedFile exercises the flattened-title scheme a
+// kubernetes/*.yaml supporting file is pushed and pulled back under, since
+// these backends are what actually moves it over the wire.
+func TestPushPullFlattenedNestedFile(t *testing.T) {
+	for name, client := range backends() {
+		name, client := name, client
+		t.Run(name, func(t *testing.T) {
+			host := newTestRegistry(t)
+			ref := host + "/stacks/nodejs:latest"
+			const flattenedName = "kubernetes-deploy.yaml"
+
+			layers := []Layer{
+				{Name: flattenedName, MediaType: "application/vnd.devfileio.kubernetes.layer.v1+yaml", Content: []byte("kind: Deployment")},
+			}
+
+			if _, err := client.Push(context.Background(), ref, layers, "application/vnd.devfileio.devfile.config.v2+json"); err != nil {
+				t.Fatalf("%s: push failed: %v", name, err)
+			}
+
+			pulled, err := client.Pull(context.Background(), ref, []string{"application/vnd.devfileio.kubernetes.layer.v1+yaml"})
+			if err != nil {
+				t.Fatalf("%s: pull failed: %v", name, err)
+			}
+			got, ok := pulled[flattenedName]
+			if !ok {
+				t.Fatalf("%s: expected %s layer, got %v", name, flattenedName, pulled)
+			}
+			if string(got) != "kind: Deployment" {
+				t.Fatalf("%s: unexpected content %q", name, got)
+			}
+		})
+	}
+}
+
+func TestManifest(t *testing.T) {
+	for name, client := range backends() {
+		name, client := name, client
+		t.Run(name, func(t *testing.T) {
+			host := newTestRegistry(t)
+			ref := host + "/stacks/nodejs:latest"
+
+			layers := []Layer{
+				{Name: "devfile.yaml", MediaType: "application/vnd.devfileio.devfile.layer.v1", Content: []byte("schemaVersion: 2.0.0")},
+			}
+			if _, err := client.Push(context.Background(), ref, layers, "application/vnd.devfileio.devfile.config.v2+json"); err != nil {
+				t.Fatalf("%s: push failed: %v", name, err)
+			}
+
+			manifest, err := client.Manifest(context.Background(), ref)
+			if err != nil {
+				t.Fatalf("%s: manifest failed: %v", name, err)
+			}
+			if len(manifest) == 0 {
+				t.Fatalf("%s: expected non-empty manifest", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package ociclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/deislabs/oras/pkg/content"
+	"github.com/deislabs/oras/pkg/oras"
+
+	"github.com/containerd/containerd/remotes/docker"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// orasClient is the original backend, pushing and pulling via
+// github.com/deislabs/oras against a plain-HTTP registry.
+type orasClient struct{}
+
+func (c *orasClient) Push(ctx context.Context, ref string, layers []Layer, configMediaType string) (Descriptor, error) {
+	resolver := docker.NewResolver(docker.ResolverOptions{PlainHTTP: true})
+	memoryStore := content.NewMemoryStore()
+
+	pushContents := make([]ocispec.Descriptor, 0, len(layers))
+	for _, l := range layers {
+		pushContents = append(pushContents, memoryStore.Add(l.Name, l.MediaType, l.Content))
+	}
+
+	desc, err := oras.Push(ctx, resolver, ref, memoryStore, pushContents, oras.WithConfigMediaType(configMediaType))
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("failed to push %s: %v", ref, err)
+	}
+	return Descriptor{Digest: string(desc.Digest), MediaType: desc.MediaType, Size: desc.Size}, nil
+}
+
+func (c *orasClient) Pull(ctx context.Context, ref string, allowedMediaTypes []string) (map[string][]byte, error) {
+	resolver := docker.NewResolver(docker.ResolverOptions{PlainHTTP: true})
+	memoryStore := content.NewMemoryStore()
+
+	_, layerDescs, err := oras.Pull(ctx, resolver, ref, memoryStore, oras.WithAllowedMediaTypes(allowedMediaTypes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %v", ref, err)
+	}
+
+	result := map[string][]byte{}
+	for _, desc := range layerDescs {
+		name := desc.Annotations[ocispec.AnnotationTitle]
+		if name == "" {
+			continue
+		}
+		_, bytes, ok := memoryStore.GetByName(name)
+		if !ok {
+			continue
+		}
+		result[name] = bytes
+	}
+	return result, nil
+}
+
+func (c *orasClient) Manifest(ctx context.Context, ref string) ([]byte, error) {
+	resolver := docker.NewResolver(docker.ResolverOptions{PlainHTTP: true})
+
+	_, desc, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %v", ref, err)
+	}
+	fetcher, err := resolver.Fetcher(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain fetcher for %s: %v", ref, err)
+	}
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest for %s: %v", ref, err)
+	}
+	defer rc.Close()
+
+	manifest, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest for %s: %v", ref, err)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,151 @@
+// Package scheduler tracks a TTL per cached stack entry and evicts expired
+// entries on a timer, persisting its state to disk so evictions survive
+// restarts.
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry represents a single cached stack and the time at which it expires.
+type Entry struct {
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// EvictFunc removes the cached content for name. It must be idempotent: it
+// is expected to return nil if the entry is already gone.
+type EvictFunc func(name string) error
+
+// Scheduler tracks cached entries and evicts the ones that have expired.
+type Scheduler struct {
+	mu          sync.Mutex
+	entries     map[string]Entry
+	ttl         time.Duration
+	maxSize     int
+	persistPath string
+	onEvict     EvictFunc
+}
+
+// New creates a Scheduler with the given TTL and max cache size, restoring
+// any previously persisted entries from persistPath if it exists.
+func New(persistPath string, ttl time.Duration, maxSize int, onEvict EvictFunc) (*Scheduler, error) {
+	s := &Scheduler{
+		entries:     map[string]Entry{},
+		ttl:         ttl,
+		maxSize:     maxSize,
+		persistPath: persistPath,
+		onEvict:     onEvict,
+	}
+
+	if bytes, err := ioutil.ReadFile(persistPath); err == nil {
+		var entries []Entry
+		if err := json.Unmarshal(bytes, &entries); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal scheduler state %s: %v", persistPath, err)
+		}
+		for _, e := range entries {
+			s.entries[e.Name] = e
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read scheduler state %s: %v", persistPath, err)
+	}
+
+	return s, nil
+}
+
+// Track records or refreshes the TTL for name, evicting the oldest entry
+// first if the cache is already at maxSize.
+func (s *Scheduler) Track(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.entries[name]; !exists && s.maxSize > 0 && len(s.entries) >= s.maxSize {
+		var oldest Entry
+		for _, e := range s.entries {
+			if oldest.Name == "" || e.ExpiresAt.Before(oldest.ExpiresAt) {
+				oldest = e
+			}
+		}
+		if oldest.Name != "" {
+			if err := s.evictLocked(oldest.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.entries[name] = Entry{Name: name, ExpiresAt: time.Now().Add(s.ttl)}
+	return s.persistLocked()
+}
+
+// Has reports whether name has a live, unexpired cache entry.
+func (s *Scheduler) Has(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, exists := s.entries[name]
+	return exists && time.Now().Before(e.ExpiresAt)
+}
+
+// Run starts a background loop that checks for expired entries every
+// interval and evicts them. It blocks until stop is closed.
+func (s *Scheduler) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.evictExpired()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *Scheduler) evictExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for name, e := range s.entries {
+		if now.After(e.ExpiresAt) {
+			if err := s.evictLocked(name); err != nil {
+				log.Printf("failed to evict cached stack %s: %v\n", name, err)
+			}
+		}
+	}
+}
+
+// evictLocked removes name from the cache. It is a no-op if the entry is
+// already gone, making eviction idempotent.
+func (s *Scheduler) evictLocked(name string) error {
+	if _, exists := s.entries[name]; !exists {
+		return nil
+	}
+	if err := s.onEvict(name); err != nil {
+		return fmt.Errorf("failed to evict %s: %v", name, err)
+	}
+	delete(s.entries, name)
+	return s.persistLocked()
+}
+
+func (s *Scheduler) persistLocked() error {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	bytes, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduler state: %v", err)
+	}
+	if err := ioutil.WriteFile(s.persistPath, bytes, 0644); err != nil {
+		return fmt.Errorf("failed to persist scheduler state to %s: %v", s.persistPath, err)
+	}
+	return nil
+}
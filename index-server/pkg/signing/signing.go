@@ -0,0 +1,113 @@
+// Package signing provides detached signature generation and verification
+// for devfile stack artifacts served by the index server.
+package signing
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// TrustStore holds the set of public keys that signatures are verified against.
+type TrustStore struct {
+	keys []*rsa.PublicKey
+}
+
+// LoadTrustStore reads every PEM-encoded public key in dir and returns a
+// TrustStore that verifies against all of them.
+func LoadTrustStore(dir string) (*TrustStore, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust store directory %s: %v", dir, err)
+	}
+
+	store := &TrustStore{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		keyBytes, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key %s: %v", entry.Name(), err)
+		}
+		pub, err := parsePublicKey(keyBytes)
+		if err != nil {
+			continue
+		}
+		store.keys = append(store.keys, pub)
+	}
+
+	if len(store.keys) == 0 {
+		return nil, fmt.Errorf("no valid public keys found in %s", dir)
+	}
+	return store, nil
+}
+
+// Verify returns true if sig is a valid signature over digest from any key
+// in the trust store.
+func (t *TrustStore) Verify(digest, sig []byte) bool {
+	hashed := sha256.Sum256(digest)
+	for _, pub := range t.keys {
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], sig); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadPrivateKey reads a PEM-encoded RSA private key from path, to be used
+// for signing artifacts at push time.
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	keyBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %v", path, err)
+	}
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block in %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %v", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an RSA key", path)
+	}
+	return rsaKey, nil
+}
+
+// Sign produces a detached signature over digest using key.
+func Sign(key *rsa.PrivateKey, digest []byte) ([]byte, error) {
+	hashed := sha256.Sum256(digest)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign digest: %v", err)
+	}
+	return sig, nil
+}
+
+func parsePublicKey(keyBytes []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA public key")
+	}
+	return rsaPub, nil
+}